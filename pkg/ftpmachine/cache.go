@@ -0,0 +1,275 @@
+package ftpmachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/RyanDevlin/planet-pulse/pkg/ferror"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+/***********************************************************************
+This file implements the on-disk cache backing FTPCache. Cached file
+bytes live under FTPCache.path/FTPCache.name/data, mirroring the remote
+server's directory structure and filenames exactly (so e.g.
+products/trends/co2/co2_weekly_mlo.csv on the remote lands at the same
+relative path in the data dir) — this lets pkg/ftpserver serve that
+directory straight off disk and have clients LIST/RETR it exactly as
+they would against the upstream server. Sidecar bookkeeping (the
+"<key>.meta" files recording remote modification time, size, and
+resolved remote path, plus the persisted LRU ".index") lives in a
+separate meta dir so it never shows up in a directory listing of the
+served data. The in-memory hashicorp/golang-lru index tracks recency so
+the cache can be bounded by MaxEntries and MaxDiskBytes, and is
+persisted on Close() so a restart comes back up warm.
+***********************************************************************/
+
+const defaultMaxEntries = 128
+
+// cacheMeta is the sidecar persisted alongside a cached file's data.
+type cacheMeta struct {
+	ModTime      time.Time `json:"mod_time"`
+	Size         int64     `json:"size"`
+	ResolvedPath string    `json:"resolved_path"`
+	Filename     string    `json:"filename"`
+}
+
+// NewFTPCache builds an FTPCache rooted at filepath.Join(path, name), loading any
+// index persisted by a previous Close(). maxEntries <= 0 falls back to defaultMaxEntries.
+// maxDiskBytes <= 0 means unbounded disk usage (only MaxEntries is enforced).
+func NewFTPCache(path, name string, maxEntries int, maxDiskBytes int64) (*FTPCache, error) {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+
+	fc := &FTPCache{
+		path:         path,
+		name:         name,
+		maxEntries:   maxEntries,
+		maxDiskBytes: maxDiskBytes,
+	}
+
+	if err := os.MkdirAll(fc.dataDir(), 0755); err != nil {
+		return nil, errors.New("NewFTPCache: could not create cache data dir '" + fc.dataDir() + "': " + err.Error())
+	}
+	if err := os.MkdirAll(fc.metaDir(), 0755); err != nil {
+		return nil, errors.New("NewFTPCache: could not create cache meta dir '" + fc.metaDir() + "': " + err.Error())
+	}
+
+	cache, err := lru.NewWithEvict(maxEntries, fc.onEvicted)
+	if err != nil {
+		return nil, err
+	}
+	fc.cache = cache
+
+	fc.loadIndex() // best effort; a missing or corrupt index just starts cold
+
+	return fc, nil
+}
+
+// Get returns the contents of filename at path, serving a local copy when it is
+// still fresh according to the remote server's modification time, and refreshing
+// the cache on a miss or stale entry.
+func (fc *FTPCache) Get(server *FTPServer, filename, path string) ([]byte, error) {
+	key := fc.key(path, filename)
+
+	if v, ok := fc.cache.Get(key); ok {
+		meta := v.(cacheMeta)
+		entry, err := server.statEntry(filename, meta.ResolvedPath)
+		if err == nil && entry.Time.Equal(meta.ModTime) {
+			if data, readErr := ioutil.ReadFile(fc.dataFile(meta.ResolvedPath, meta.Filename)); readErr == nil {
+				return data, nil
+			}
+		}
+		if err == nil {
+			return fc.refresh(server, key, entry)
+		}
+	}
+
+	// Not cached (or the cached path no longer resolves): fall back to the full
+	// directory search to locate the file, then remember the resolved path.
+	entry, err := server.GetMeta(filename, path)
+	if err != nil {
+		return nil, err
+	}
+	return fc.refresh(server, key, entry)
+}
+
+func (fc *FTPCache) refresh(server *FTPServer, key string, entry FTPEntry) ([]byte, error) {
+	data, err := server.GetFile(entry.Name, entry.Path, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fc.put(key, entry, data); err != nil {
+		ferror.ErrorLog(err)
+	}
+	return data, nil
+}
+
+func (fc *FTPCache) put(key string, entry FTPEntry, data []byte) error {
+	dataPath := fc.dataFile(entry.Path, entry.Name)
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(dataPath, data, 0644); err != nil {
+		return err
+	}
+
+	meta := cacheMeta{
+		ModTime:      entry.Time,
+		Size:         int64(len(data)),
+		ResolvedPath: entry.Path,
+		Filename:     entry.Name,
+	}
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(fc.metaFile(key), metaBytes, 0644); err != nil {
+		return err
+	}
+
+	// lru.Cache.Add updates an already-present key's value in place without
+	// invoking onEvicted, so account for the old entry's size ourselves before
+	// adding the new one, or diskBytes drifts upward forever on a warm cache.
+	if old, ok := fc.cache.Peek(key); ok {
+		atomic.AddInt64(&fc.diskBytes, -old.(cacheMeta).Size)
+	}
+	fc.cache.Add(key, meta)
+	atomic.AddInt64(&fc.diskBytes, meta.Size)
+
+	for fc.maxDiskBytes > 0 && atomic.LoadInt64(&fc.diskBytes) > fc.maxDiskBytes {
+		if _, _, ok := fc.cache.RemoveOldest(); !ok {
+			break
+		}
+	}
+
+	return nil
+}
+
+// onEvicted removes a cached entry's data and sidecar files from disk and accounts
+// for the freed space. It is invoked synchronously by the lru.Cache whenever an
+// entry is removed or evicted.
+func (fc *FTPCache) onEvicted(key interface{}, value interface{}) {
+	k := key.(string)
+	meta, ok := value.(cacheMeta)
+	if ok {
+		atomic.AddInt64(&fc.diskBytes, -meta.Size)
+		os.Remove(fc.dataFile(meta.ResolvedPath, meta.Filename))
+	}
+	os.Remove(fc.metaFile(k))
+}
+
+// Close persists the current LRU ordering to disk so a future NewFTPCache() call
+// against the same path/name comes back up with a warm index.
+func (fc *FTPCache) Close() error {
+	keys := fc.cache.Keys() // oldest to newest, per hashicorp/golang-lru
+	index := make([]string, 0, len(keys))
+	for _, k := range keys {
+		index = append(index, k.(string))
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fc.indexFile(), data, 0644)
+}
+
+func (fc *FTPCache) loadIndex() {
+	data, err := ioutil.ReadFile(fc.indexFile())
+	if err != nil {
+		return
+	}
+
+	var index []string
+	if json.Unmarshal(data, &index) != nil {
+		return
+	}
+
+	for _, key := range index {
+		metaBytes, err := ioutil.ReadFile(fc.metaFile(key))
+		if err != nil {
+			continue
+		}
+		var meta cacheMeta
+		if json.Unmarshal(metaBytes, &meta) != nil {
+			continue
+		}
+		fc.cache.Add(key, meta)
+		atomic.AddInt64(&fc.diskBytes, meta.Size)
+	}
+}
+
+func (fc *FTPCache) dir() string {
+	return filepath.Join(fc.path, fc.name)
+}
+
+// dataDir is the root of the served, remote-mirrored tree: cached files live here
+// at their real remote path and filename, with nothing else mixed in.
+func (fc *FTPCache) dataDir() string {
+	return filepath.Join(fc.dir(), "data")
+}
+
+// metaDir holds bookkeeping (per-entry ".meta" sidecars and the persisted LRU
+// ".index") out of band from dataDir, so it never appears in a served listing.
+func (fc *FTPCache) metaDir() string {
+	return filepath.Join(fc.dir(), "meta")
+}
+
+// Root returns the local on-disk directory mirroring the remote server's tree, so
+// other packages (e.g. pkg/ftpserver) can serve it directly without needing access
+// to FTPCache's unexported fields.
+func (fc *FTPCache) Root() string {
+	return fc.dataDir()
+}
+
+func (fc *FTPCache) dataFile(remotePath, filename string) string {
+	return filepath.Join(fc.dataDir(), remotePath, filename)
+}
+
+func (fc *FTPCache) metaFile(key string) string {
+	return filepath.Join(fc.metaDir(), key+".meta")
+}
+
+func (fc *FTPCache) indexFile() string {
+	return filepath.Join(fc.metaDir(), ".index")
+}
+
+// key turns a (path, filename) pair into a flat string safe to use as a file name.
+func (fc *FTPCache) key(path, filename string) string {
+	joined := filepath.ToSlash(filepath.Join("/", path, filename))
+	return strings.ReplaceAll(strings.TrimPrefix(joined, "/"), "/", "_")
+}
+
+// statEntry looks up a single file's metadata directly under path, without
+// recursing into subdirectories the way GetMeta/search does.
+func (server *FTPServer) statEntry(filename, path string) (FTPEntry, error) {
+	list, err := server.ftpList(context.Background(), path)
+	if err != nil {
+		return FTPEntry{}, err
+	}
+
+	for _, entry := range list {
+		if entry.Name == filename {
+			return FTPEntry{
+				Name:   entry.Name,
+				Path:   path,
+				Target: entry.Target,
+				Type:   entry.Type,
+				Size:   entry.Size,
+				Time:   entry.Time,
+			}, nil
+		}
+	}
+
+	return FTPEntry{}, errors.New("statEntry: Error file '" + filename + "' not found at '" + path + "'")
+}
@@ -1,12 +1,15 @@
 package ftpmachine
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,6 +17,7 @@ import (
 	"github.com/RyanDevlin/planet-pulse/pkg/ferror"
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/jlaffaye/ftp"
+	"golang.org/x/sync/errgroup"
 )
 
 /***********************************************************************
@@ -31,17 +35,52 @@ about the intricacies of the jlaffaye/ftp library.
 // won't even accept more than 8 or so simultaneous connections from a single IP, so this theoretical max is really just for fun.
 const connectionLimit = 65535
 
+// defaultMaxDepth is the recursion cap NewFTPMachine gives FTPServer.MaxDepth when
+// the caller doesn't set one. MaxDepth is the only real guard search() has against
+// a symlink cycle on the remote server, so leaving it at the zero value (unlimited)
+// would let such a cycle recurse forever; this default bounds it while still being
+// deep enough for any realistic mirror layout.
+const defaultMaxDepth = 32
+
 // FTPServer represents a remote FTP server
 type FTPServer struct {
-	Hostname           string
-	Username           string
-	Password           string
-	Timeout            int        // This should be optional
-	PendingConnections int32      // The unique ID of a new runtime connection. Is incremented each time we make a new connection. Needs to be initialized to 0 in order to properly flush pending connections.
-	Connections        chan int32 // A buffer channel semaphore used to limite the number of connections we open concurrently to the remote server
-	haltSearch         chan bool  // A channel to signal the program to stop searching the server
-	cancelPending      chan bool  // A channel used to cancel all pending server connections
-	attemptedConn      int32      // Incremented with each call to ftpSyncConnect()
+	Hostname      string
+	Port          int
+	Username      string
+	Password      string
+	Timeout       int        // This should be optional
+	Connections   chan int32 // A buffer channel semaphore used to limite the number of connections we open concurrently to the remote server
+	attemptedConn int32      // Incremented with each call to ftpSyncConnect()
+
+	// MaxDepth caps how many subdirectories search() will recurse into below the
+	// starting path. NewFTPMachine defaults this to defaultMaxDepth; 0 means
+	// unlimited. This is the only guard against a symlink cycle on the remote
+	// server: search()'s visited set is keyed by path, and a cycle walked through
+	// a symlink produces an ever-lengthening, never-repeating path, so it will not
+	// be caught by visited alone.
+	MaxDepth int
+
+	// MaxRetries caps how many times a transient FTP failure (e.g. a 421 "too many connections")
+	// is retried before giving up. Defaults to 5 if left at 0. This should be optional.
+	MaxRetries int
+	cache      *FTPCache // Set via FTPMachine.UseCache. nil means caching is disabled.
+
+	// IdleTimeout is how long a pooled connection may sit unused before it is closed.
+	// Defaults to 60 seconds if left at 0. This should be optional.
+	IdleTimeout time.Duration
+	pool        *connPool
+	poolOnce    sync.Once
+
+	// TLS enables implicit FTPS, dialing straight into a TLS session (usually port 990).
+	TLS bool
+	// ExplicitTLS enables explicit FTPS, issuing "AUTH TLS" over a plaintext control connection before upgrading it.
+	ExplicitTLS bool
+	// NoCheckCertificate disables verification of the remote server's TLS certificate. Only useful for self-signed mirrors.
+	NoCheckCertificate bool
+	// TLSCACertificateFile, if set, is a PEM file used instead of the system cert pool to verify the server's certificate.
+	TLSCACertificateFile string
+	// DisableTLS13 caps the TLS handshake at 1.2. Some older FTPS servers negotiate 1.3 badly.
+	DisableTLS13 bool
 }
 
 type FTPConnection struct {
@@ -60,12 +99,16 @@ type FTPEntry struct {
 	Time   time.Time
 }
 
-// FTPCache represents a local cache of FTP data
+// FTPCache represents a local, on-disk cache of FTP data, bounded by MaxEntries and MaxDiskBytes.
+// Build one with NewFTPCache.
 type FTPCache struct {
-	path    string   // Local path where the cache will live
-	name    string   // Name of the cache. This is what the dir under the above path will be named.
-	filemap []string // A slice of file paths on the remote server
-	cache   *lru.Cache
+	path         string   // Local path where the cache will live
+	name         string   // Name of the cache. This is what the dir under the above path will be named.
+	filemap      []string // A slice of file paths on the remote server
+	cache        *lru.Cache
+	maxEntries   int
+	maxDiskBytes int64
+	diskBytes    int64 // Current disk usage in bytes. Accessed atomically.
 }
 
 type CacheEntry struct {
@@ -76,18 +119,33 @@ type CacheEntry struct {
 
 type FTPMachine struct {
 	Server FTPServer
-	Cache  FTPCache
+	Cache  *FTPCache
 	// Should have methods to destroy existing cache maybe
 }
 
+// UseCache turns on caching for this machine, backing it with an on-disk FTPCache rooted
+// at filepath.Join(cacheDir, name). maxEntries <= 0 falls back to defaultMaxEntries, and
+// maxDiskBytes <= 0 means disk usage is only bounded by maxEntries.
+func (machine *FTPMachine) UseCache(cacheDir, name string, maxEntries int, maxDiskBytes int64) error {
+	cache, err := NewFTPCache(cacheDir, name, maxEntries, maxDiskBytes)
+	if err != nil {
+		return err
+	}
+	machine.Cache = cache
+	machine.Server.cache = cache
+	return nil
+}
+
 // NewFTPMachine builds an FTPMachine struct representing a remote FTP server and a local cache of file data for that server.
 // ==========================================================================================================================
 // hostname: Any valid domain name representing the remote FTP server,
+// port: The TCP port the remote FTP server listens on. Use 21 for plaintext/explicit FTPS, 990 for implicit FTPS,
 // username: The username to authenticate to the FTP server,
 // password: The password to authenticate to the FTP server,
 // maxConnections: The number of concurrent connections from a single IP that the remote FTP server will allow. This is usually fairly low, around 8.
 // If you are receiving “421 Too many connections” errors from the server, reduce this value and try again.
-func NewFTPMachine(hostname, username, password string, maxConnections int) (*FTPMachine, error) { // TODO: Add timeout parameter
+// tls: Dial the server using implicit FTPS. For explicit FTPS or advanced TLS settings, set FTPServer.ExplicitTLS and friends after construction.
+func NewFTPMachine(hostname string, port int, username, password string, maxConnections int, tls bool) (*FTPMachine, error) { // TODO: Add timeout parameter
 	// Server parameters are validated here. It's possible to call lower level functions on their own, but this skips
 	// the variable validation performed in NewFTPMachine(), which could result in unexpected behavior.
 
@@ -95,24 +153,28 @@ func NewFTPMachine(hostname, username, password string, maxConnections int) (*FT
 		return nil, errors.New("NewFTPMachine: Supplied hostname '" + hostname + "' is not valid.")
 	}
 
+	if port < 1 || port > 65535 {
+		return nil, errors.New("NewFTPMachine: port must be between 1 and 65535.")
+	}
+
 	if maxConnections > connectionLimit || maxConnections < 1 {
 		return nil, errors.New("NewFTPMachine: maxConnections must be between 1 and " + fmt.Sprint(connectionLimit) + ".")
 	}
 
 	server := FTPServer{
-		Hostname:           hostname,
-		Username:           username,
-		Password:           password,
-		PendingConnections: 0,
-		Connections:        make(chan int32, maxConnections),
-		haltSearch:         make(chan bool),
-		cancelPending:      make(chan bool),
-		attemptedConn:      0,
+		Hostname:      hostname,
+		Port:          port,
+		Username:      username,
+		Password:      password,
+		Connections:   make(chan int32, maxConnections),
+		attemptedConn: 0,
+		TLS:           tls,
+		MaxDepth:      defaultMaxDepth,
 	}
 
 	ftpmachine := FTPMachine{
 		Server: server,
-		Cache:  FTPCache{}, // TODO: Add caching functionality
+		Cache:  nil, // Disabled until FTPMachine.UseCache is called
 	}
 
 	return &ftpmachine, nil
@@ -125,12 +187,23 @@ func NewFTPMachine(hostname, username, password string, maxConnections int) (*FT
 // if file cannot be located.
 func (server *FTPServer) Get(filename, path string, offset uint64) ([]byte, error) {
 
+	if server.cache != nil {
+		data, err := server.cache.Get(server, filename, path)
+		if ferror.ErrorLog(err) {
+			return nil, err
+		}
+		if offset > uint64(len(data)) {
+			return nil, errors.New("Get: offset " + fmt.Sprint(offset) + " is beyond the end of '" + filename + "'")
+		}
+		return data[offset:], nil
+	}
+
 	ftpEntry, err := server.GetMeta(filename, path)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := server.GetFile(ftpEntry.Name, ftpEntry.Path, false, offset)
+	data, err := server.GetFile(ftpEntry.Name, ftpEntry.Path, offset)
 	if ferror.ErrorLog(err) {
 		return nil, err
 	}
@@ -143,115 +216,163 @@ func (server *FTPServer) Get(filename, path string, offset uint64) ([]byte, erro
 // will search for the file on all sub-paths.
 func (server *FTPServer) GetMeta(filename, path string) (FTPEntry, error) {
 
-	result := make(chan FTPEntry)
-	sigError := make(chan error)
-	wg := new(sync.WaitGroup)
-	wg.Add(1)
-	go server.search(filename, path, result, sigError, wg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	g, gctx := errgroup.WithContext(ctx)
+	visited := new(sync.Map)
+	result := make(chan FTPEntry, 1)
+
+	g.Go(func() error {
+		return server.search(gctx, cancel, filename, path, 0, visited, result)
+	})
+
+	err := g.Wait()
 
-	// Wait for server to be fully searched
-	wg.Wait()
 	select {
 	case ftpEntry := <-result:
 		return ftpEntry, nil
-	case err := <-sigError:
-		return FTPEntry{}, err
 	default:
-		return FTPEntry{}, errors.New("Get: Error file '" + filename + "' not found on the server")
 	}
+
+	if err != nil {
+		return FTPEntry{}, err
+	}
+	return FTPEntry{}, errors.New("Get: Error file '" + filename + "' not found on the server")
 }
 
 // Returns data read from offset to end of a file
 // Returns an error if the file was not found at the given path
-func (server *FTPServer) GetFile(filename string, path string, cancelable bool, offset uint64) ([]byte, error) {
+func (server *FTPServer) GetFile(filename string, path string, offset uint64) ([]byte, error) {
 
 	// Establish FTP connection
-	conn, err := server.ftpSyncConnect(cancelable)
+	conn, err := server.ftpSyncConnect(context.Background())
 	defer server.ftpDisconnect(conn)
 	if err != nil {
 		return nil, err
 	}
 
-	// Using ChangeDir as "stat" function
-	result := conn.c.ChangeDir(path)
-	if result != nil {
-		return nil, errors.New("ftpList: Error '" + path + "' does not exist on the server")
-		//return nil, errors.New("DNE")
-	}
-
 	// Because ftpSyncConnect() only returns after a connection has been established or cancelled, we can check this to throw out the request if it was cancelled
 	if conn.cancelled {
 		return nil, nil
 	}
 
-	// Pull down the file
-	r, err := conn.c.RetrFrom(filename, offset)
+	var buf []byte
+	var dirErr bool
+	p := newPacer(server.MaxRetries)
+	err = p.call(func() error {
+		// Using ChangeDir as "stat" function
+		if cdErr := conn.c.ChangeDir(path); cdErr != nil {
+			dirErr = true
+			return cdErr
+		}
+		dirErr = false
+
+		// Pull down the file
+		r, retrErr := conn.c.RetrFrom(filename, offset)
+		if retrErr != nil {
+			return retrErr
+		}
+
+		data, readErr := ioutil.ReadAll(r)
+		// r.Close() drains the data connection and reads the final 226 reply off the
+		// control connection. Skipping it leaves that reply unread, which NoOp() then
+		// trips over the next time this conn is checked out of the pool, so the pool
+		// ends up discarding every conn GetFile ever touches.
+		if closeErr := r.Close(); closeErr != nil && readErr == nil {
+			readErr = closeErr
+		}
+		if readErr != nil {
+			return readErr
+		}
+		buf = data
+		return nil
+	})
 	if err != nil {
+		if dirErr {
+			return nil, errors.New("ftpList: Error '" + path + "' does not exist on the server")
+		}
 		return nil, err
 	}
 
-	buf, err := ioutil.ReadAll(r)
 	return buf, nil
 }
 
-// Given a filename, this function searches all sub-paths at the
-// provided path for the file. It returns the path to the file if found.
-// If no path is provided, this searches all sub-paths from the root of the server.
-// If no path is found this returns an error.
-func (server *FTPServer) search(filename, path string, result chan FTPEntry, sigError chan error, wg *sync.WaitGroup) {
-	// TODO: Add error logging and handling. If file not found,
-	// should log that. If verbosity is on, list all paths searched..
-	path = filepath.Join("/", path)
-
+// search walks the server below path looking for filename, fanning out one goroutine
+// per subdirectory via errgroup so the first hit cancels every sibling search still in
+// flight. visited records every absolute path already searched in this call tree,
+// avoiding re-listing a directory reachable via more than one route; it does not by
+// itself protect against symlink cycles (a cycle produces distinct, ever-lengthening
+// paths), so MaxDepth is what actually bounds those. A subdirectory that fails to list
+// (e.g. permission denied) is logged and skipped rather than failing the whole search,
+// since the target may still be found down a sibling branch.
+//
+// Entry metadata (type, size, modification time) comes from ftpList, which prefers
+// MLSD (RFC 3659) over the server's LIST output whenever the server advertises support
+// for it via FEAT, falling back to LIST parsing only when it doesn't.
+func (server *FTPServer) search(ctx context.Context, cancel context.CancelFunc, filename, path string, depth int, visited *sync.Map, result chan FTPEntry) error {
 	select {
-	case <-server.haltSearch: // If one of the goroutines found the file, the halt channel will close which will unblock the case and immediately halt the search
-		wg.Done()
-		return
+	case <-ctx.Done(): // Another branch already found the file (or the search was cancelled)
+		return nil
 	default:
-		fmt.Println("Searching remote location:", "ftp://"+filepath.Join(server.Hostname, path))
-		list, err := server.ftpList(path, true)
-		if err != nil {
-			wg.Done()
-			sigError <- err // TODO: Proper error handling
-			return
-		}
+	}
+
+	path = filepath.Join("/", path)
+
+	if server.MaxDepth > 0 && depth > server.MaxDepth {
+		return nil
+	}
+
+	if _, alreadyVisited := visited.LoadOrStore(path, true); alreadyVisited {
+		return nil
+	}
+
+	fmt.Println("Searching remote location:", "ftp://"+filepath.Join(server.Hostname, path))
+	list, err := server.ftpList(ctx, path)
+	if ferror.ErrorLog(err) {
+		// A single subdirectory failing to list (permission denied, transient
+		// error, ...) shouldn't fail the whole search: the target may still be
+		// found down a sibling branch, so skip this branch instead of aborting
+		// the errgroup.
+		return nil
+	}
 
-		for _, entry := range list {
-			if entry.Name == filename {
-
-				ftpEntry := FTPEntry{
-					Name:   entry.Name,
-					Path:   path,
-					Target: entry.Target,
-					Type:   entry.Type,
-					Size:   entry.Size,
-					Time:   entry.Time,
-				}
-				fmt.Println("==== FOUND IT: ", ftpEntry.Path)
-				pending := int(atomic.LoadInt32(&server.PendingConnections))
-				for i := 0; i < pending; i++ {
-					server.cancelPending <- true // Flushes all queued connections
-				}
-				close(server.haltSearch) // Stop searching
-				wg.Done()
-				result <- ftpEntry
-				return
+	for _, entry := range list {
+		if entry.Name == filename {
+			ftpEntry := FTPEntry{
+				Name:   entry.Name,
+				Path:   path,
+				Target: entry.Target,
+				Type:   entry.Type,
+				Size:   entry.Size,
+				Time:   entry.Time,
 			}
-			if entry.Type == ftp.EntryTypeFolder {
-				subPath := filepath.Join(path, entry.Name)
-				wg.Add(1)
-				go server.search(filename, subPath, result, sigError, wg)
+			fmt.Println("==== FOUND IT: ", ftpEntry.Path)
+			select {
+			case result <- ftpEntry:
+			default:
 			}
+			cancel() // Stop sibling searches
+			return nil
+		}
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for _, entry := range list {
+		if entry.Type == ftp.EntryTypeFolder {
+			subPath := filepath.Join(path, entry.Name)
+			g.Go(func() error {
+				return server.search(gctx, cancel, filename, subPath, depth+1, visited, result)
+			})
 		}
 	}
 
-	wg.Done()
-	return
+	return g.Wait()
 }
 
-func (server *FTPServer) ftpList(path string, cancelable bool) ([]*ftp.Entry, error) {
+func (server *FTPServer) ftpList(ctx context.Context, path string) ([]*ftp.Entry, error) {
 	// Establish FTP connection
-	conn, err := server.ftpSyncConnect(cancelable)
+	conn, err := server.ftpSyncConnect(ctx)
 	defer server.ftpDisconnect(conn)
 	if err != nil {
 		return nil, err
@@ -261,22 +382,36 @@ func (server *FTPServer) ftpList(path string, cancelable bool) ([]*ftp.Entry, er
 		return nil, nil
 	}
 
-	// Because of the way jlaffaye/ftp was written, the List() function will not tell us if the path exists or not
-	// Instead we have to use ChangeDir to first test this, which is dumb
-	result := conn.c.ChangeDir(path)
-	if result != nil {
-		return nil, errors.New("ftpList: Error '" + path + "' does not exist on the server")
-	}
-
-	list, err := conn.c.List(path)
+	var list []*ftp.Entry
+	var dirErr bool
+	p := newPacer(server.MaxRetries)
+	err = p.call(func() error {
+		// Because of the way jlaffaye/ftp was written, the List() function will not tell us if the path exists or not
+		// Instead we have to use ChangeDir to first test this, which is dumb
+		if cdErr := conn.c.ChangeDir(path); cdErr != nil {
+			dirErr = true
+			return cdErr
+		}
+		dirErr = false
+
+		// List() prefers MLSD over LIST internally whenever the server's FEAT reply
+		// advertises support for it, giving reliable type/modify/size facts instead of
+		// whatever LIST format the server happens to emit.
+		var listErr error
+		list, listErr = conn.c.List(path)
+		return listErr
+	})
 	if err != nil {
+		if dirErr {
+			return nil, errors.New("ftpList: Error '" + path + "' does not exist on the server")
+		}
 		return nil, err
 	}
 
-	return list, err
+	return list, nil
 }
 
-func (server *FTPServer) ftpSyncConnect(cancelable bool) (*FTPConnection, error) {
+func (server *FTPServer) ftpSyncConnect(ctx context.Context) (*FTPConnection, error) {
 	// Increment the number of connection attempts
 	currID := atomic.AddInt32(&server.attemptedConn, 1)
 
@@ -286,52 +421,117 @@ func (server *FTPServer) ftpSyncConnect(cancelable bool) (*FTPConnection, error)
 		connID:    currID, // Used to mark this connection attempt with a unique ID
 	}
 
-	if cancelable { // This loop allows pending connection requests to be aborted by sending to the server.CancelPending channel
-		// Increment pending counter only for cancelable requests
-		atomic.AddInt32(&server.PendingConnections, 1)
-		for {
-			select {
-			case <-server.cancelPending: // Sends to this channel flush pending connection requests
-				atomic.AddInt32(&server.PendingConnections, -1)
-				connection.cancelled = true
-				return connection, nil
-			case server.Connections <- int32(connection.connID): // Buffered channel semaphore to limit the number of concurrent connections
-				atomic.AddInt32(&server.PendingConnections, -1)
-				conn, err := server.ftpConnect(connection)
-				connection.c = conn
-				return connection, err
-			}
-		}
+	select {
+	case <-ctx.Done(): // The caller's context was cancelled (e.g. a sibling search already found the file)
+		connection.cancelled = true
+		return connection, nil
+	case server.Connections <- int32(connection.connID): // Buffered channel semaphore to limit the number of concurrent connections
+		conn, err := server.checkoutConn(connection)
+		connection.c = conn
+		return connection, err
 	}
+}
+
+// connPool lazily builds this server's connection pool, defaulting IdleTimeout to 60s.
+func (server *FTPServer) connPool() *connPool {
+	server.poolOnce.Do(func() {
+		idleTimeout := server.IdleTimeout
+		if idleTimeout <= 0 {
+			idleTimeout = defaultIdleTimeout
+		}
+		server.pool = newConnPool(idleTimeout)
+	})
+	return server.pool
+}
 
-	// This request cannot be aborted
-	server.Connections <- int32(connection.connID)
-	conn, err := server.ftpConnect(connection)
-	connection.c = conn
-	return connection, err
+// checkoutConn reuses a pooled, already-logged-in ServerConn when one is idle and
+// still alive, falling back to dialing a fresh one via ftpConnect otherwise.
+func (server *FTPServer) checkoutConn(connection *FTPConnection) (*ftp.ServerConn, error) {
+	if c := server.connPool().checkout(); c != nil {
+		if err := c.NoOp(); err == nil {
+			return c, nil
+		}
+		c.Quit() // stale connection, discard and dial a fresh one below
+	}
+	return server.ftpConnect(connection)
 }
 
 func (server *FTPServer) ftpConnect(connection *FTPConnection) (*ftp.ServerConn, error) {
 
-	dialAddr := net.JoinHostPort(server.Hostname, "21") // jlaffaye/ftp requires the port. TODO: maybe add a port override, although that detracts from the simplicity goal of this package.
-	c, err := ftp.Dial(dialAddr, ftp.DialWithTimeout(10*time.Second))
-	if err != nil { // TODO: integrate with error handling
-		log.Fatal(err)
+	dialAddr := net.JoinHostPort(server.Hostname, strconv.Itoa(server.Port))
+
+	dialOptions := []ftp.DialOption{ftp.DialWithTimeout(10 * time.Second)}
+	if server.TLS || server.ExplicitTLS {
+		tlsConfig, err := server.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		if server.TLS {
+			dialOptions = append(dialOptions, ftp.DialWithTLS(tlsConfig))
+		} else {
+			dialOptions = append(dialOptions, ftp.DialWithExplicitTLS(tlsConfig))
+		}
 	}
-	fmt.Println("New connection established for connection #", connection.connID)
 
-	// Log in to the FTP server
-	err = c.Login(server.Username, server.Password)
+	var c *ftp.ServerConn
+	p := newPacer(server.MaxRetries)
+	err := p.call(func() error {
+		var dialErr error
+		c, dialErr = ftp.Dial(dialAddr, dialOptions...)
+		if dialErr != nil {
+			return dialErr
+		}
+
+		// Log in to the FTP server
+		if loginErr := c.Login(server.Username, server.Password); loginErr != nil {
+			c.Quit()
+			return loginErr
+		}
+		return nil
+	})
 	if err != nil {
-		log.Fatal(err) // TODO: integrate with error handling
+		return nil, err
 	}
+	fmt.Println("New connection established for connection #", connection.connID)
+
 	return c, nil
 }
 
+// tlsConfig builds the *tls.Config used for implicit and explicit FTPS connections
+// based on the NoCheckCertificate, TLSCACertificateFile and DisableTLS13 options.
+func (server *FTPServer) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         server.Hostname,
+		InsecureSkipVerify: server.NoCheckCertificate,
+	}
+
+	if server.DisableTLS13 {
+		config.MaxVersion = tls.VersionTLS12
+	}
+
+	if server.TLSCACertificateFile != "" {
+		pem, err := ioutil.ReadFile(server.TLSCACertificateFile)
+		if err != nil {
+			return nil, errors.New("tlsConfig: could not read TLSCACertificateFile '" + server.TLSCACertificateFile + "': " + err.Error())
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("tlsConfig: no certificates could be parsed from TLSCACertificateFile '" + server.TLSCACertificateFile + "'")
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
 func (server *FTPServer) ftpDisconnect(connection *FTPConnection) {
 	if !connection.cancelled {
 		id := <-server.Connections // Release connection we are done with
-		connection.c.Quit()
+		if connection.c != nil {
+			// Return to the pool instead of closing; the pool's drain timer will close it if it goes unused
+			server.connPool().checkin(connection.c)
+		}
 		fmt.Println("Completed disconnection for connection #", id)
 		return
 	}
@@ -0,0 +1,96 @@
+package ftpmachine
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/textproto"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+/***********************************************************************
+This file implements a small pacer, modeled after rclone's lib/pacer,
+which retries a transient FTP failure with exponential backoff instead
+of propagating it straight to the caller. This is needed because
+anonymous mirrors such as NOAA's will happily answer "421 Too many
+connections" under load, which is recoverable if you just wait a bit
+and try again.
+***********************************************************************/
+
+const (
+	defaultMinSleep   = 10 * time.Millisecond
+	defaultMaxSleep   = 2 * time.Second
+	defaultDecay      = 2 // exponential decay constant
+	defaultMaxRetries = 5
+)
+
+// pacer retries a function with exponential backoff, up to maxRetries times,
+// as long as shouldRetry() judges the returned error transient.
+type pacer struct {
+	minSleep   time.Duration
+	maxSleep   time.Duration
+	decay      uint
+	maxRetries int
+}
+
+// newPacer builds a pacer with the package defaults. maxRetries of 0 or less falls back to defaultMaxRetries.
+func newPacer(maxRetries int) *pacer {
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	return &pacer{
+		minSleep:   defaultMinSleep,
+		maxSleep:   defaultMaxSleep,
+		decay:      defaultDecay,
+		maxRetries: maxRetries,
+	}
+}
+
+// call runs fn, retrying with exponential backoff while shouldRetry(err) is true,
+// up to p.maxRetries times after the first attempt. It returns the last error encountered.
+func (p *pacer) call(fn func() error) error {
+	var err error
+	sleep := p.minSleep
+
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+
+		if !shouldRetry(err) || attempt == p.maxRetries {
+			return err
+		}
+
+		time.Sleep(sleep)
+		sleep = time.Duration(math.Min(float64(p.maxSleep), float64(sleep)*float64(p.decay)))
+	}
+
+	return err
+}
+
+// shouldRetry inspects an error returned by jlaffaye/ftp and reports whether
+// it represents a transient condition worth retrying: a 421 "too many
+// connections", any other 4xx transient FTP reply, or a network-level error.
+func shouldRetry(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		if protoErr.Code == ftp.StatusNotAvailable {
+			return true
+		}
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,97 @@
+package ftpmachine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+/***********************************************************************
+This file implements a small pool of live *ftp.ServerConn objects,
+checked out by ftpSyncConnect and checked back in by ftpDisconnect. This
+avoids paying a fresh TCP handshake + login round trip on every single
+FTP command, which used to happen on every call to ftpConnect. A
+connection that sits idle for longer than its pool's drain timeout is
+closed rather than kept open forever.
+***********************************************************************/
+
+const defaultIdleTimeout = 60 * time.Second
+
+// pooledConn is an idle connection sitting in a connPool, along with the timer
+// that will close it if it isn't checked out again before the drain timeout.
+type pooledConn struct {
+	conn  *ftp.ServerConn
+	timer *time.Timer
+}
+
+// connPool holds idle, already-authenticated ServerConns ready for reuse.
+type connPool struct {
+	mu    sync.Mutex
+	idle  []*pooledConn
+	drain time.Duration
+}
+
+func newConnPool(drain time.Duration) *connPool {
+	return &connPool{drain: drain}
+}
+
+// checkout pops the most recently returned idle connection, or nil if none is idle.
+func (p *connPool) checkout() *ftp.ServerConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.idle)
+	if n == 0 {
+		return nil
+	}
+
+	pc := p.idle[n-1]
+	p.idle = p.idle[:n-1]
+	pc.timer.Stop()
+	return pc.conn
+}
+
+// checkin returns conn to the pool and starts its idle drain timer. If conn sits
+// unused for longer than p.drain, it is closed and discarded from the pool.
+func (p *connPool) checkin(conn *ftp.ServerConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pc := &pooledConn{conn: conn}
+	pc.timer = time.AfterFunc(p.drain, func() {
+		if p.drop(pc) {
+			conn.Quit()
+		}
+	})
+	p.idle = append(p.idle, pc)
+}
+
+// drop removes pc from the idle list if it is still there, reporting whether it
+// did. If pc was already checked out by the time its drain timer fired, drop
+// leaves the idle list untouched and reports false, so the caller knows conn now
+// belongs to whoever checked it out and must not be closed.
+func (p *connPool) drop(pc *pooledConn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, c := range p.idle {
+		if c == pc {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// closeAll closes every idle connection and empties the pool.
+func (p *connPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pc := range p.idle {
+		pc.timer.Stop()
+		pc.conn.Quit()
+	}
+	p.idle = nil
+}
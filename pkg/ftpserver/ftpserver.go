@@ -0,0 +1,96 @@
+package ftpserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/RyanDevlin/planet-pulse/pkg/ftpmachine"
+	ftpserverlib "github.com/fclairamb/ftpserverlib"
+	"github.com/spf13/afero"
+)
+
+/***********************************************************************
+This file wraps fclairamb/ftpserverlib to republish an FTPCache's
+on-disk contents as an anonymous, read-only FTP server. This lets
+downstream tools connect to the local planetpulse daemon and LIST/RETR
+cached climate data files (e.g. co2_weekly_mlo.csv) exactly as they
+would against the upstream NOAA mirror, turning planetpulse into a
+caching FTP proxy the same way rclone's cmd/serve/ftp re-exports any
+backend.
+***********************************************************************/
+
+// Server republishes an *ftpmachine.FTPCache as an anonymous, read-only FTP server.
+type Server struct {
+	Hostname string
+	Port     int
+
+	driver *driver
+	server *ftpserverlib.FtpServer
+}
+
+// NewServer builds a Server that serves cache's contents rooted at cache.Root().
+// hostname is advertised to clients for passive-mode data connections; port is the
+// control port to listen on.
+func NewServer(hostname string, port int, cache *ftpmachine.FTPCache) (*Server, error) {
+	if port < 1 || port > 65535 {
+		return nil, errors.New("NewServer: port must be between 1 and 65535.")
+	}
+
+	d := &driver{
+		hostname: hostname,
+		port:     port,
+		root:     cache.Root(),
+	}
+
+	return &Server{
+		Hostname: hostname,
+		Port:     port,
+		driver:   d,
+		server:   ftpserverlib.NewFtpServer(d),
+	}, nil
+}
+
+// ListenAndServe starts accepting FTP control connections. It blocks until Stop is called.
+func (s *Server) ListenAndServe() error {
+	return s.server.ListenAndServe()
+}
+
+// Stop shuts down the FTP server and closes any open client connections.
+func (s *Server) Stop() error {
+	return s.server.Stop()
+}
+
+// driver implements ftpserverlib.MainDriver on top of a read-only view of the cache directory.
+type driver struct {
+	hostname string
+	port     int
+	root     string
+}
+
+func (d *driver) GetSettings() (*ftpserverlib.Settings, error) {
+	return &ftpserverlib.Settings{
+		ListenAddr: net.JoinHostPort("", strconv.Itoa(d.port)),
+		PublicHost: d.hostname,
+	}, nil
+}
+
+func (d *driver) ClientConnected(cc ftpserverlib.ClientContext) (string, error) {
+	return "Welcome to the planetpulse cached NOAA mirror", nil
+}
+
+func (d *driver) ClientDisconnected(cc ftpserverlib.ClientContext) {}
+
+// AuthUser only allows anonymous, read-only access to the cache directory, mirroring
+// the anonymous access the upstream NOAA server itself grants.
+func (d *driver) AuthUser(cc ftpserverlib.ClientContext, user, pass string) (ftpserverlib.ClientDriver, error) {
+	if user != "anonymous" {
+		return nil, errors.New("AuthUser: only anonymous read-only access is supported")
+	}
+	return afero.NewReadOnlyFs(afero.NewBasePathFs(afero.NewOsFs(), d.root)), nil
+}
+
+func (d *driver) GetTLSConfig() (*tls.Config, error) {
+	return nil, nil
+}